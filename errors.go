@@ -0,0 +1,30 @@
+package main
+
+// StatusError reports a process exit code alongside an error message,
+// mirroring how the docker CLI's cli.StatusError distinguishes flag misuse
+// and runtime failures from a plain internal error.
+type StatusError struct {
+	Status     string
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return e.Status
+}
+
+// exitCodeFor maps an error returned from Execute to a process exit code,
+// printing a StatusError's message to stderr the way the docker CLI does
+// before returning its exit code.
+func exitCodeFor(err error) int {
+	if statusErr, ok := err.(StatusError); ok {
+		if statusErr.Status != "" {
+			println(statusErr.Status)
+		}
+		if statusErr.StatusCode != 0 {
+			return statusErr.StatusCode
+		}
+		return 1
+	}
+	println(err.Error())
+	return 1
+}
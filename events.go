@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// logWorkerPoolSize bounds how many ContainerInspect/ContainerLogs calls run
+// concurrently, so a burst of container deaths doesn't serialize behind
+// slow log fetches.
+const logWorkerPoolSize = 8
+
+// ContainerEvent holds parameters for a Container Event
+type ContainerEvent struct {
+	ContainerID, ContainerName, Image string
+	Title, Body, Cmd, ExitCode        string
+	Action                            string
+	Tags                              []string
+	EventMessage                      events.Message
+	OOMKilled                         bool
+	Error                             string
+	StartedAt, FinishedAt             string
+	RestartCount                      int
+}
+
+func setupCloseHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		logger.Info("Ctrl+C pressed, exiting")
+		os.Exit(0)
+	}()
+}
+
+// buildEventFilters turns the repeated --filter flags into a filters.Args
+// suitable for types.EventsOptions, the same way the docker CLI parses
+// --filter on `docker events`.
+func buildEventFilters(rawFilters []string) (filters.Args, error) {
+	filterArgs := filters.NewArgs()
+	for _, f := range rawFilters {
+		var err error
+		filterArgs, err = filters.ParseFlag(f, filterArgs)
+		if err != nil {
+			return filterArgs, err
+		}
+	}
+	return filterArgs, nil
+}
+
+// matchesLabelFilters reports whether a container event's labels satisfy
+// every configured --label-filter, so operators can scope a single watcher
+// to e.g. com.datadoghq.watch=true without every container's die event
+// flooding DataDog.
+func matchesLabelFilters(msg events.Message, required []string) bool {
+	for _, f := range required {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 {
+			logger.Warn("ignoring malformed --label-filter, expected key=value", "filter", f)
+			continue
+		}
+		if msg.Actor.Attributes[parts[0]] != parts[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func logDebugEvent(msg events.Message, inspectResponse types.ContainerJSON) {
+	logger.Debug("docker event", "message", msg, "inspect", inspectResponse)
+}
+
+// drainEvents reads from msgs/errs, invoking handle for each message, until
+// either channel closes. It returns nil when the stream ends normally and
+// the context's error when ctx is canceled first.
+func drainEvents(ctx context.Context, msgs <-chan events.Message, errs <-chan error, handle func(events.Message)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-errs:
+			if !ok {
+				return nil
+			}
+			metricsDockerAPIErrors.WithLabelValues("events").Inc()
+			logger.Warn("docker events stream error", "error", err)
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			handle(msg)
+		}
+	}
+}
+
+// newEventDispatcher starts a bounded pool of workers that handle non-zero
+// container die events, and returns a handle func to feed it docker events
+// plus a close func to drain and stop the workers.
+func newEventDispatcher(ctx context.Context, cli *client.Client, sinks []Sink, labelFilters []string, logBytes int) (handle func(events.Message), closeFn func()) {
+	jobs := make(chan events.Message)
+	var wg sync.WaitGroup
+	for i := 0; i < logWorkerPoolSize; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				handleDieEvent(ctx, cli, sinks, msg, logBytes)
+			}
+		}()
+	}
+
+	handle = func(msg events.Message) {
+		metricsEventsReceived.WithLabelValues(msg.Type, msg.Action).Inc()
+
+		if !matchesLabelFilters(msg, labelFilters) {
+			metricsEventsDropped.WithLabelValues("label_filter").Inc()
+			return
+		}
+		if msg.Type != "container" || (msg.Action != "die" && msg.Action != "exec_die") {
+			return
+		}
+		if msg.Actor.Attributes["exitCode"] == "0" {
+			return
+		}
+		jobs <- msg
+	}
+	closeFn = func() {
+		close(jobs)
+		wg.Wait()
+	}
+	return handle, closeFn
+}
+
+// handleDieEvent inspects a dead container, attaches its recent logs and
+// exit reason, and fans the resulting event out to every sink. Failures
+// reaching the daemon for this one event are logged and skipped rather
+// than aborting the watcher.
+func handleDieEvent(ctx context.Context, cli *client.Client, sinks []Sink, msg events.Message, logBytes int) {
+	inspectResponse, err := cli.ContainerInspect(ctx, msg.Actor.ID)
+	if err != nil {
+		metricsDockerAPIErrors.WithLabelValues("container_inspect").Inc()
+		logger.Error("inspecting container", "container_id", msg.Actor.ID, "error", err)
+		return
+	}
+	logDebugEvent(msg, inspectResponse)
+
+	containerEvent := ContainerEvent{
+		ContainerID:   msg.Actor.ID,
+		ContainerName: msg.Actor.Attributes["name"],
+		Image:         msg.Actor.Attributes["image"],
+		Cmd:           strings.Join(inspectResponse.Config.Cmd, " "),
+		ExitCode:      msg.Actor.Attributes["exitCode"],
+		Action:        msg.Action,
+		EventMessage:  msg,
+		OOMKilled:     inspectResponse.State.OOMKilled,
+		Error:         inspectResponse.State.Error,
+		StartedAt:     inspectResponse.State.StartedAt,
+		FinishedAt:    inspectResponse.State.FinishedAt,
+		RestartCount:  inspectResponse.RestartCount,
+		Body:          fetchLogTail(ctx, cli, msg.Actor.ID, logBytes),
+	}
+
+	for key, value := range inspectResponse.Config.Labels {
+		containerEvent.Tags = append(containerEvent.Tags, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	switch msg.Action {
+	case "die":
+		containerEvent.Title = fmt.Sprintf("%s container exited non-zero: %s", containerEvent.ContainerName, containerEvent.ExitCode)
+	case "exec_die":
+		containerEvent.Title = fmt.Sprintf("%s container process exited non-zero: %s", containerEvent.ContainerName, containerEvent.ExitCode)
+	}
+
+	emitAll(ctx, sinks, containerEvent)
+}
+
+// fetchLogTail pulls the last 50 lines of stdout/stderr for a container,
+// demultiplexing the docker log stream and truncating to maxBytes so a
+// sink with a body size limit (e.g. DataDog's event body) isn't blown.
+func fetchLogTail(ctx context.Context, cli *client.Client, containerID string, maxBytes int) string {
+	reader, err := cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       "50",
+		Timestamps: true,
+	})
+	if err != nil {
+		metricsDockerAPIErrors.WithLabelValues("container_logs").Inc()
+		logger.Error("fetching container logs", "container_id", containerID, "error", err)
+		return ""
+	}
+	defer reader.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, reader); err != nil {
+		logger.Error("demultiplexing container logs", "container_id", containerID, "error", err)
+	}
+
+	combined := stdout.String() + stderr.String()
+	if maxBytes > 0 && len(combined) > maxBytes {
+		combined = combined[len(combined)-maxBytes:]
+	}
+	return combined
+}
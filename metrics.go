@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricsEventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "docker_events_received_total",
+		Help: "Total docker events received from the daemon, by type and action.",
+	}, []string{"type", "action"})
+
+	metricsEventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "docker_events_dropped_total",
+		Help: "Total docker events dropped before processing, by reason.",
+	}, []string{"reason"})
+
+	metricsSinkEmits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "docker_watcher_sink_emit_total",
+		Help: "Total sink emit attempts, by sink and result.",
+	}, []string{"sink", "result"})
+
+	metricsSinkEmitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "docker_watcher_sink_emit_duration_seconds",
+		Help: "Latency of sink emit calls, by sink.",
+	}, []string{"sink"})
+
+	metricsDockerAPIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "docker_api_errors_total",
+		Help: "Total errors returned from docker API calls, by operation.",
+	}, []string{"operation"})
+
+	metricsEventsReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "docker_events_reconnects_total",
+		Help: "Total times the docker events stream was reopened after ending.",
+	})
+
+	metricsEventsConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "docker_events_connected",
+		Help: "1 if the docker events stream is currently connected, 0 otherwise.",
+	})
+)
+
+// serveMetrics starts the Prometheus /metrics endpoint on addr in the
+// background, if configured, and stops it when ctx is done. A blank addr
+// disables the endpoint entirely.
+func serveMetrics(ctx context.Context, addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		logger.Info("serving metrics", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("metrics server failed", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replaySince        string
+	replayUntil        string
+	replayFilters      []string
+	replayLabelFilters []string
+)
+
+// newReplayCommand backfills docker events between --since and --until,
+// so a watcher that was down for a while can pick up what it missed
+// instead of silently losing events across a restart.
+func newReplayCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Backfill docker events between --since and --until",
+		Args:  cobra.NoArgs,
+		RunE:  runReplay,
+	}
+
+	cmd.Flags().StringVar(&replaySince, "since", "", "replay events at or after this time (RFC3339, unix timestamp, or docker duration like \"10m\")")
+	cmd.Flags().StringVar(&replayUntil, "until", "", "replay events at or before this time (RFC3339, unix timestamp, or docker duration like \"10m\")")
+	cmd.Flags().StringArrayVar(&replayFilters, "filter", nil, "docker events filter, repeatable (e.g. --filter label=env=prod --filter event=die)")
+	cmd.Flags().StringArrayVar(&replayLabelFilters, "label-filter", nil, "require container label to match key=value, repeatable; events from containers missing a match are dropped")
+	cmd.MarkFlagRequired("since")
+
+	return cmd
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	sinks, err := newSinks(output)
+	if err != nil {
+		return StatusError{Status: err.Error(), StatusCode: 1}
+	}
+	defer closeSinks(sinks)
+
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return err
+	}
+	cli.NegotiateAPIVersion(ctx)
+
+	eventFilters, err := buildEventFilters(replayFilters)
+	if err != nil {
+		return StatusError{Status: err.Error(), StatusCode: 1}
+	}
+
+	serveMetrics(ctx, metricsAddr)
+
+	handle, closeDispatcher := newEventDispatcher(ctx, cli, sinks, replayLabelFilters, logBytes)
+	defer closeDispatcher()
+
+	logger.Info("replaying docker events", "since", replaySince, "until", replayUntil)
+	msgs, errs := cli.Events(ctx, types.EventsOptions{
+		Since:   replaySince,
+		Until:   replayUntil,
+		Filters: eventFilters,
+	})
+	return drainEvents(ctx, msgs, errs, handle)
+}
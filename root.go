@@ -0,0 +1,100 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// logger is the structured logger used throughout docker-watcher, set up
+// from --log-format/--log-level before any subcommand runs.
+var logger *slog.Logger
+
+var (
+	buildVersion  string
+	output        []string
+	statsdHost    string
+	logFormat     string
+	templateFiles []string
+
+	webhookURL        string
+	webhookHeaders    []string
+	webhookHMACSecret string
+	webhookTimeout    time.Duration
+
+	syslogNetwork string
+	syslogAddr    string
+	syslogTag     string
+
+	filePath       string
+	fileMaxSizeMB  int
+	fileMaxBackups int
+
+	logBytes int
+
+	logLevel    string
+	metricsAddr string
+)
+
+func defaultStatsdHost() string {
+	if h := os.Getenv("DOGSTATSD_HOST"); h != "" {
+		return h
+	}
+	return "localhost:8125"
+}
+
+// newRootCommand assembles the docker-watcher root command and its
+// watch/stats/replay subcommands, similar to how the docker CLI's
+// SetupRootCommand wires persistent flags shared across subcommands.
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "docker-watcher",
+		Short:         "Do things in response to docker container events",
+		Long:          "docker-watcher watches the docker events stream and reports on it.",
+		Version:       buildVersion,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	root.PersistentFlags().StringArrayVar(&output, "output", []string{"datadog"}, "where to send events: datadog, stdout, webhook, syslog, file; repeatable to fan out to multiple sinks")
+	root.PersistentFlags().StringVar(&statsdHost, "statsd-host", defaultStatsdHost(), "address:port for DataDogStatsD listener")
+	root.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log output format: text|json")
+	root.PersistentFlags().StringArrayVar(&templateFiles, "template-file", nil, "override a sink's event template, repeatable (format: name=path, e.g. stdout=/etc/docker-watcher/stdout.tmpl)")
+
+	root.PersistentFlags().StringVar(&webhookURL, "webhook-url", "", "URL to POST events to when --output=webhook")
+	root.PersistentFlags().StringArrayVar(&webhookHeaders, "webhook-header", nil, "extra header to send with webhook requests, repeatable (format: Key:Value)")
+	root.PersistentFlags().StringVar(&webhookHMACSecret, "webhook-hmac-secret", "", "if set, sign webhook bodies with HMAC-SHA256 in the X-Signature-256 header")
+	root.PersistentFlags().DurationVar(&webhookTimeout, "webhook-timeout", 10*time.Second, "timeout for webhook sink HTTP requests, so an unresponsive endpoint can't hang a dispatch worker")
+
+	root.PersistentFlags().StringVar(&syslogNetwork, "syslog-network", "", "network for the syslog sink (tcp, udp, or empty for the local syslog daemon)")
+	root.PersistentFlags().StringVar(&syslogAddr, "syslog-addr", "", "address for the syslog sink when --syslog-network is set")
+	root.PersistentFlags().StringVar(&syslogTag, "syslog-tag", "docker-watcher", "tag to use for syslog sink messages")
+
+	root.PersistentFlags().StringVar(&filePath, "file-path", "", "path to append JSON-lines events to when --output=file")
+	root.PersistentFlags().IntVar(&fileMaxSizeMB, "file-max-size-mb", 100, "rotate the file sink's output once it reaches this size")
+	root.PersistentFlags().IntVar(&fileMaxBackups, "file-max-backups", 5, "number of rotated file sink backups to keep")
+
+	root.PersistentFlags().IntVar(&logBytes, "log-bytes", 4096, "cap the attached container log tail to this many bytes")
+
+	root.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level: debug|info|warn|error")
+	root.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "address to serve Prometheus /metrics on (disabled if empty)")
+
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		logger = newLogger(logFormat, logLevel)
+		return applyTemplateOverrides(templateFiles)
+	}
+
+	root.AddCommand(newWatchCommand())
+	root.AddCommand(newStatsCommand())
+	root.AddCommand(newReplayCommand())
+
+	return root
+}
+
+// Execute runs the docker-watcher root command and returns any error for
+// main to translate into a process exit code.
+func Execute() error {
+	return newRootCommand().Execute()
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+)
+
+// Sink delivers a ContainerEvent to a destination. Implementations must be
+// safe to call concurrently. Emit errors are logged by the caller and never
+// stop delivery to the other configured sinks.
+type Sink interface {
+	Emit(ctx context.Context, e ContainerEvent) error
+	Close() error
+	// Name identifies the sink kind (e.g. "datadog") for metrics and logs.
+	Name() string
+}
+
+const datadogEventTemplate = `Name: {{.ContainerName}}
+Image: {{.Image}}
+Exit Code: {{.ExitCode}}
+ID: {{.ContainerID}}
+{{if .OOMKilled}}OOM Killed: true
+{{end}}{{if .Error}}Error: {{.Error}}
+{{end}}Started: {{.StartedAt}}
+Finished: {{.FinishedAt}}
+Restart Count: {{.RestartCount}}
+{{if .Body}}
+Last logs:
+{{.Body}}{{end}}`
+
+const stdoutEventTemplate = `{{.ContainerName}} {{if eq .Action "exec_die" }}process{{end}} exited non-zero: {{.ExitCode}}
+Action: {{.EventMessage.Action}}
+Image: {{.Image}}
+ID: {{.ContainerID}}
+{{if .OOMKilled}}OOM Killed: true
+{{end}}{{if .Error}}Error: {{.Error}}
+{{end}}Started: {{.StartedAt}}
+Finished: {{.FinishedAt}}
+Restart Count: {{.RestartCount}}
+{{if .Body}}
+Last logs:
+{{.Body}}{{end}}`
+
+const syslogEventTemplate = `{{.ContainerName}} exited non-zero: {{.ExitCode}} (image {{.Image}}, id {{.ContainerID}}){{if .OOMKilled}} OOM-killed{{end}}{{if .Error}} error={{.Error}}{{end}}`
+
+// sinkTemplates holds the default text/template body for each sink kind
+// that renders free-form text, overridable per-kind via
+// --template-file name=path.
+var sinkTemplates = map[string]string{
+	"datadog": datadogEventTemplate,
+	"stdout":  stdoutEventTemplate,
+	"syslog":  syslogEventTemplate,
+}
+
+// applyTemplateOverrides loads "name=path" pairs from --template-file and
+// replaces the matching entry in sinkTemplates.
+func applyTemplateOverrides(overrides []string) error {
+	for _, o := range overrides {
+		parts := strings.SplitN(o, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid --template-file %q, expected name=path", o)
+		}
+		name, path := parts[0], parts[1]
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading template file for %q: %w", name, err)
+		}
+		sinkTemplates[name] = string(content)
+	}
+	return nil
+}
+
+func evalTemplate(t string, e ContainerEvent) (string, error) {
+	buf := new(bytes.Buffer)
+	tmpl, err := template.New("containerEventBody").Parse(t)
+	if err != nil {
+		return "", err
+	}
+	if err := tmpl.Execute(buf, e); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// newSinks builds one Sink per --output value, in the order given, so
+// events fan out to every configured destination concurrently.
+func newSinks(names []string) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range names {
+		sink, err := newSink(name)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func newSink(name string) (Sink, error) {
+	switch name {
+	case "datadog":
+		client, err := statsd.New(statsdHost)
+		if err != nil {
+			return nil, err
+		}
+		return &datadogSink{client: client}, nil
+	case "stdout":
+		return &stdoutSink{}, nil
+	case "webhook":
+		return newWebhookSink()
+	case "syslog":
+		return newSyslogSink()
+	case "file":
+		return newFileSink()
+	default:
+		return nil, fmt.Errorf("unknown output sink %q", name)
+	}
+}
+
+// emitAll fans an event out to every configured sink concurrently. A
+// failing sink is logged and does not block or cancel delivery to the
+// others.
+func emitAll(ctx context.Context, sinks []Sink, e ContainerEvent) {
+	var wg sync.WaitGroup
+	for _, sink := range sinks {
+		wg.Add(1)
+		go func(s Sink) {
+			defer wg.Done()
+			start := time.Now()
+			err := s.Emit(ctx, e)
+			metricsSinkEmitDuration.WithLabelValues(s.Name()).Observe(time.Since(start).Seconds())
+			if err != nil {
+				metricsSinkEmits.WithLabelValues(s.Name(), "failure").Inc()
+				logger.Error("sink emit failed", "sink", s.Name(), "error", err)
+				return
+			}
+			metricsSinkEmits.WithLabelValues(s.Name(), "success").Inc()
+		}(sink)
+	}
+	wg.Wait()
+}
+
+// closeSinks closes every sink, logging but not failing on individual
+// close errors.
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			logger.Error("closing sink", "sink", sink.Name(), "error", err)
+		}
+	}
+}
+
+// datadogSink emits events as DataDog events over dogstatsd.
+type datadogSink struct {
+	client *statsd.Client
+}
+
+func (s *datadogSink) Emit(ctx context.Context, e ContainerEvent) error {
+	body, err := evalTemplate(sinkTemplates["datadog"], e)
+	if err != nil {
+		return err
+	}
+	e.Body = body
+	event := statsd.NewEvent(e.Title, e.Body)
+	event.AggregationKey = e.ContainerID
+	event.AlertType = statsd.Error
+	event.SourceTypeName = "DOCKER"
+	event.Tags = e.Tags
+	return s.client.Event(event)
+}
+
+func (s *datadogSink) Close() error { return s.client.Close() }
+
+func (s *datadogSink) Name() string { return "datadog" }
+
+// stdoutSink prints the rendered event template to stdout.
+type stdoutSink struct{}
+
+func (s *stdoutSink) Emit(ctx context.Context, e ContainerEvent) error {
+	body, err := evalTemplate(sinkTemplates["stdout"], e)
+	if err != nil {
+		return err
+	}
+	fmt.Println(body)
+	return nil
+}
+
+func (s *stdoutSink) Close() error { return nil }
+
+func (s *stdoutSink) Name() string { return "stdout" }
+
+// syslogSink writes the rendered event template as a syslog warning.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink() (Sink, error) {
+	w, err := syslog.Dial(syslogNetwork, syslogAddr, syslog.LOG_WARNING|syslog.LOG_DAEMON, syslogTag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: w}, nil
+}
+
+func (s *syslogSink) Emit(ctx context.Context, e ContainerEvent) error {
+	body, err := evalTemplate(sinkTemplates["syslog"], e)
+	if err != nil {
+		return err
+	}
+	return s.writer.Warning(body)
+}
+
+func (s *syslogSink) Close() error { return s.writer.Close() }
+
+func (s *syslogSink) Name() string { return "syslog" }
+
+// webhookSink POSTs the event as JSON to an HTTP endpoint, optionally
+// signing the body with an HMAC-SHA256 secret the way GitHub/Stripe style
+// webhooks do.
+type webhookSink struct {
+	url        string
+	headers    map[string]string
+	hmacSecret string
+	client     *http.Client
+}
+
+func newWebhookSink() (Sink, error) {
+	if webhookURL == "" {
+		return nil, fmt.Errorf("--webhook-url is required for the webhook output sink")
+	}
+	headers := map[string]string{}
+	for _, h := range webhookHeaders {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --webhook-header %q, expected Key:Value", h)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return &webhookSink{
+		url:        webhookURL,
+		headers:    headers,
+		hmacSecret: webhookHMACSecret,
+		client:     &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+func (s *webhookSink) Emit(ctx context.Context, e ContainerEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if s.hmacSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.hmacSecret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error { return nil }
+
+func (s *webhookSink) Name() string { return "webhook" }
+
+// fileSink appends each event as a JSON line, rotating the file once it
+// crosses --file-max-size-mb and keeping --file-max-backups old files.
+type fileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func newFileSink() (Sink, error) {
+	if filePath == "" {
+		return nil, fmt.Errorf("--file-path is required for the file output sink")
+	}
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileSink{
+		path:       filePath,
+		maxBytes:   int64(fileMaxSizeMB) * 1024 * 1024,
+		maxBackups: fileMaxBackups,
+		f:          f,
+		size:       info.Size(),
+	}, nil
+}
+
+func (s *fileSink) Emit(ctx context.Context, e ContainerEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if s.maxBytes > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate closes the current file, drops the oldest backup, shifts
+// .1..maxBackups-1 up by one, and opens a fresh file at path, keeping
+// at most maxBackups backups on disk.
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i > 0; i-- {
+		oldPath := fmt.Sprintf("%s.%d", s.path, i)
+		newPath := fmt.Sprintf("%s.%d", s.path, i+1)
+		os.Rename(oldPath, newPath)
+	}
+	if s.maxBackups > 0 {
+		if err := os.Rename(s.path, s.path+".1"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.size = 0
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+func (s *fileSink) Name() string { return "file" }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+var statsConcurrency int
+
+// newStatsCommand streams periodic CPU/memory/network/blkio stats for
+// running containers to StatsD, reconciling the watched set off the
+// existing docker events stream rather than polling.
+func newStatsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Stream periodic container stats to StatsD",
+		Args:  cobra.NoArgs,
+		RunE:  runStats,
+	}
+
+	cmd.Flags().IntVar(&statsConcurrency, "stats-concurrency", 20, "maximum number of container stats streams open to the daemon at once")
+
+	return cmd
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	setupCloseHandler()
+
+	statsdClient, err := statsd.New(statsdHost)
+	if err != nil {
+		return err
+	}
+	defer statsdClient.Close()
+
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return err
+	}
+	cli.NegotiateAPIVersion(ctx)
+
+	collector := newStatsCollector(cli, statsdClient, statsConcurrency)
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		collector.watch(ctx, c.ID)
+	}
+
+	serveMetrics(ctx, metricsAddr)
+
+	logger.Info("streaming container stats")
+	msgs, errs := cli.Events(ctx, types.EventsOptions{})
+	return drainEvents(ctx, msgs, errs, func(msg events.Message) {
+		metricsEventsReceived.WithLabelValues(msg.Type, msg.Action).Inc()
+		if msg.Type != "container" {
+			return
+		}
+		switch msg.Action {
+		case "start":
+			collector.watch(ctx, msg.Actor.ID)
+		case "die", "stop", "destroy":
+			collector.unwatch(msg.Actor.ID)
+		}
+	})
+}
+
+// statsCollector tracks one ContainerStats stream per watched container,
+// capping how many are open at once since each holds an HTTP connection
+// to the daemon.
+type statsCollector struct {
+	cli    *client.Client
+	statsd *statsd.Client
+	sem    chan struct{}
+
+	mu       sync.Mutex
+	watching map[string]*watchedStream
+}
+
+// watchedStream is the identity token stored per watched container, so a
+// stream that ends after its container has already been restarted can tell
+// whether it's still the current stream for that ID before tearing it down.
+type watchedStream struct {
+	cancel context.CancelFunc
+}
+
+func newStatsCollector(cli *client.Client, statsdClient *statsd.Client, maxConcurrent int) *statsCollector {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	return &statsCollector{
+		cli:      cli,
+		statsd:   statsdClient,
+		sem:      make(chan struct{}, maxConcurrent),
+		watching: make(map[string]*watchedStream),
+	}
+}
+
+func (c *statsCollector) watch(ctx context.Context, containerID string) {
+	c.mu.Lock()
+	if _, ok := c.watching[containerID]; ok {
+		c.mu.Unlock()
+		return
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	handle := &watchedStream{cancel: cancel}
+	c.watching[containerID] = handle
+	c.mu.Unlock()
+
+	go c.stream(streamCtx, containerID, handle)
+}
+
+func (c *statsCollector) unwatch(containerID string) {
+	c.mu.Lock()
+	handle, ok := c.watching[containerID]
+	delete(c.watching, containerID)
+	c.mu.Unlock()
+	if ok {
+		handle.cancel()
+	}
+}
+
+// unwatchIfCurrent removes containerID from the watched set only if it is
+// still mapped to ownHandle, so a stream ending after the container has
+// already been restarted (die followed by start, both racing in via the
+// events handler) doesn't clobber the newer stream's registration.
+func (c *statsCollector) unwatchIfCurrent(containerID string, ownHandle *watchedStream) {
+	c.mu.Lock()
+	if c.watching[containerID] == ownHandle {
+		delete(c.watching, containerID)
+	}
+	c.mu.Unlock()
+}
+
+func (c *statsCollector) stream(ctx context.Context, containerID string, handle *watchedStream) {
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-c.sem }()
+	defer c.unwatchIfCurrent(containerID, handle)
+
+	inspectResponse, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		metricsDockerAPIErrors.WithLabelValues("container_inspect").Inc()
+		logger.Error("inspecting container for stats", "container_id", containerID, "error", err)
+		return
+	}
+	if !inspectResponse.State.Running {
+		return
+	}
+	tags := statsTags(inspectResponse)
+
+	resp, err := c.cli.ContainerStats(ctx, containerID, true)
+	if err != nil {
+		metricsDockerAPIErrors.WithLabelValues("container_stats").Inc()
+		logger.Error("streaming container stats", "container_id", containerID, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var stats types.StatsJSON
+		if err := decoder.Decode(&stats); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				logger.Error("decoding container stats", "container_id", containerID, "error", err)
+			}
+			return
+		}
+		c.emit(stats, tags)
+	}
+}
+
+// emit reports one sample of a container's stats as StatsD gauges.
+func (c *statsCollector) emit(stats types.StatsJSON, tags []string) {
+	c.gauge("docker.container.cpu.pct", cpuPercent(stats), tags)
+	c.gauge("docker.container.mem.rss", float64(stats.MemoryStats.Stats["rss"]), tags)
+	c.gauge("docker.container.mem.limit", float64(stats.MemoryStats.Limit), tags)
+
+	for iface, netStats := range stats.Networks {
+		ifaceTags := append(append([]string{}, tags...), fmt.Sprintf("interface:%s", iface))
+		c.gauge("docker.container.net.rx_bytes", float64(netStats.RxBytes), ifaceTags)
+		c.gauge("docker.container.net.tx_bytes", float64(netStats.TxBytes), ifaceTags)
+	}
+
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			c.gauge("docker.container.blkio.read_bytes", float64(entry.Value), tags)
+		case "write":
+			c.gauge("docker.container.blkio.write_bytes", float64(entry.Value), tags)
+		}
+	}
+}
+
+func (c *statsCollector) gauge(name string, value float64, tags []string) {
+	if err := c.statsd.Gauge(name, value, tags, 1); err != nil {
+		logger.Error("emitting stats gauge", "metric", name, "error", err)
+	}
+}
+
+// cpuPercent computes CPU% the same way the docker CLI's `stats` command
+// does: the delta in total CPU usage over the delta in system CPU usage,
+// scaled by the number of online CPUs.
+func cpuPercent(stats types.StatsJSON) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+
+	return (cpuDelta / systemDelta) * onlineCPUs * 100
+}
+
+// statsTags builds the tag set for a container's stats gauges: name and
+// image plus the same label-derived tags built for container die events.
+func statsTags(inspect types.ContainerJSON) []string {
+	tags := []string{
+		fmt.Sprintf("container_name:%s", strings.TrimPrefix(inspect.Name, "/")),
+		fmt.Sprintf("image:%s", inspect.Config.Image),
+	}
+	for key, value := range inspect.Config.Labels {
+		tags = append(tags, fmt.Sprintf("%s=%s", key, value))
+	}
+	return tags
+}
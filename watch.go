@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchFilters      []string
+	watchLabelFilters []string
+)
+
+// newWatchCommand watches the docker events stream and reports non-zero
+// container exits, the tool's original behavior.
+func newWatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch docker events and report non-zero container exits",
+		Args:  cobra.NoArgs,
+		RunE:  runWatch,
+	}
+
+	cmd.Flags().StringArrayVar(&watchFilters, "filter", nil, "docker events filter, repeatable (e.g. --filter label=env=prod --filter event=die)")
+	cmd.Flags().StringArrayVar(&watchLabelFilters, "label-filter", nil, "require container label to match key=value, repeatable; events from containers missing a match are dropped")
+
+	return cmd
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	setupCloseHandler()
+
+	sinks, err := newSinks(output)
+	if err != nil {
+		return StatusError{Status: err.Error(), StatusCode: 1}
+	}
+	defer closeSinks(sinks)
+
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return err
+	}
+	cli.NegotiateAPIVersion(ctx)
+
+	eventFilters, err := buildEventFilters(watchFilters)
+	if err != nil {
+		return StatusError{Status: err.Error(), StatusCode: 1}
+	}
+
+	serveMetrics(ctx, metricsAddr)
+
+	handle, closeDispatcher := newEventDispatcher(ctx, cli, sinks, watchLabelFilters, logBytes)
+	defer closeDispatcher()
+
+	logger.Info("listening for docker events")
+	return runEventStream(ctx, cli, types.EventsOptions{Filters: eventFilters}, handle)
+}
+
+// runEventStream opens cli.Events and, if the stream ends, reopens it with
+// exponential backoff so a daemon restart or connection hiccup doesn't
+// require restarting the watcher.
+func runEventStream(ctx context.Context, cli *client.Client, opts types.EventsOptions, handle func(events.Message)) error {
+	const (
+		initialBackoff = time.Second
+		maxBackoff     = 30 * time.Second
+		minHealthyUp   = time.Minute
+	)
+	backoff := initialBackoff
+
+	for {
+		connectedAt := time.Now()
+		msgs, errs := cli.Events(ctx, opts)
+		metricsEventsConnected.Set(1)
+		err := drainEvents(ctx, msgs, errs, handle)
+		metricsEventsConnected.Set(0)
+		if err != nil {
+			return err
+		}
+
+		if time.Since(connectedAt) >= minHealthyUp {
+			backoff = initialBackoff
+		}
+
+		metricsEventsReconnects.Inc()
+		logger.Warn("docker events stream ended, reconnecting", "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}